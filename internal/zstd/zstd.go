@@ -0,0 +1,59 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zstd adapts klauspost/compress/zstd to the io.ReadCloser /
+// io.WriteCloser shapes the rest of the tree already uses for gzip, so
+// layer codecs can be swapped without leaking a third-party API into
+// exported interfaces.
+package zstd
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ReadCloser returns a reader that decompresses the zstd stream read from r.
+// Closing it releases the decoder and closes r, if r is an io.Closer.
+func ReadCloser(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{zr: zr, underlying: r}, nil
+}
+
+type reader struct {
+	zr         *zstd.Decoder
+	underlying io.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	return r.zr.Read(p)
+}
+
+func (r *reader) Close() error {
+	r.zr.Close()
+	if c, ok := r.underlying.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// WriteCloser returns a writer that zstd-compresses everything written to
+// it into w, at the given compression level. Closing it flushes the
+// remaining output to w; it does not close w.
+func WriteCloser(w io.Writer, level int) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevel(level)))
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// apiVersion is the Docker engine API version this package speaks.
+const apiVersion = "v1.39"
+
+// client is a minimal client for the subset of the Docker engine API that
+// daemon.Image and daemon.Write need: exporting and importing image tars.
+type client struct {
+	http   *http.Client
+	scheme string
+	host   string
+}
+
+func newClient(network, addr string, tlsCfg *tls.Config) *client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	c := &client{http: &http.Client{Transport: transport}, scheme: "http", host: "daemon"}
+	if tlsCfg != nil {
+		transport.TLSClientConfig = tlsCfg
+		// TLS is only applied to https URLs; a TCP daemon's address doubles
+		// as the name its certificate is verified against.
+		c.scheme = "https"
+		if network == "tcp" {
+			c.host = addr
+		}
+	}
+	return c
+}
+
+func (c *client) url(path string) string {
+	return c.scheme + "://" + c.host + "/" + apiVersion + path
+}
+
+// ImageSave streams the named image out of the daemon as a tar, in the
+// same format `docker save` produces.
+func (c *client) ImageSave(ctx context.Context, ref string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, c.url(fmt.Sprintf("/images/%s/get", ref)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("daemon: saving image %q: unexpected status %d", ref, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// ImageLoad streams a `docker load`-compatible tar into the daemon.
+func (c *client) ImageLoad(ctx context.Context, tar io.Reader) error {
+	req, err := http.NewRequest(http.MethodPost, c.url("/images/load"), tar)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("daemon: loading image: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
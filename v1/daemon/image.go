@@ -0,0 +1,36 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package daemon provides access to images stored in a local Docker daemon,
+// as an alternative to fetching them from a registry.
+package daemon
+
+import (
+	"io"
+
+	"github.com/google/go-containerregistry/name"
+	"github.com/google/go-containerregistry/v1"
+	"github.com/google/go-containerregistry/v1/tarball"
+)
+
+// Image loads the named image out of a local Docker daemon's image store,
+// by asking the daemon to export it as a tar (the same format `docker save`
+// produces) and reading that tar as a v1.Image.
+func Image(ref name.Reference, opts ...Option) (v1.Image, error) {
+	o := makeOptions(opts...)
+	c := newClient(o.network, o.addr, o.tlsCfg)
+	return tarball.Image(func() (io.ReadCloser, error) {
+		return c.ImageSave(o.ctx, ref.Name())
+	})
+}
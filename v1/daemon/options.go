@@ -0,0 +1,71 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+)
+
+// options holds the result of applying functional options.
+type options struct {
+	ctx     context.Context
+	network string
+	addr    string
+	tlsCfg  *tls.Config
+}
+
+// Option is a functional option for daemon operations.
+type Option func(*options)
+
+// WithContext sets the context used to cancel requests made to the daemon.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithHost points at a specific daemon host, either a unix socket
+// ("unix:///var/run/docker.sock") or a TCP address ("tcp://1.2.3.4:2376").
+// If unset, the local unix socket at /var/run/docker.sock is used.
+func WithHost(host string) Option {
+	return func(o *options) {
+		if addr := strings.TrimPrefix(host, "unix://"); addr != host {
+			o.network, o.addr = "unix", addr
+			return
+		}
+		o.network, o.addr = "tcp", strings.TrimPrefix(host, "tcp://")
+	}
+}
+
+// WithTLS configures TLS for talking to a TCP daemon host.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsCfg = cfg
+	}
+}
+
+func makeOptions(opts ...Option) options {
+	o := options{
+		ctx:     context.Background(),
+		network: "unix",
+		addr:    "/var/run/docker.sock",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/go-containerregistry/name"
+	"github.com/google/go-containerregistry/v1"
+)
+
+// Write saves img into the local Docker daemon as ref, by streaming a
+// `docker load`-compatible tar through a pipe into the daemon's image import
+// API. Each blob is read fully into memory, one at a time, before it is
+// written to the tar.
+func Write(ref name.Reference, img v1.Image, opts ...Option) error {
+	o := makeOptions(opts...)
+	c := newClient(o.network, o.addr, o.tlsCfg)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, ref, img))
+	}()
+	return c.ImageLoad(o.ctx, pr)
+}
+
+// writeTar serializes img as a `docker load`-compatible tar: the raw config
+// blob, one layer.tar per layer, and a manifest.json tying them together
+// under ref's tag.
+func writeTar(w io.Writer, ref name.Reference, img v1.Image) error {
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	m, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+
+	cfgName := fileName(m.Config.Digest) + ".json"
+	cfgBlob, err := img.Blob(m.Config.Digest)
+	if err != nil {
+		return err
+	}
+	cfgBytes, err := ioutil.ReadAll(cfgBlob)
+	cfgBlob.Close()
+	if err != nil {
+		return err
+	}
+	if err := writeEntry(tw, cfgName, cfgBytes); err != nil {
+		return err
+	}
+
+	layerNames := make([]string, len(m.Layers))
+	for idx, l := range m.Layers {
+		blob, err := img.Blob(l.Digest)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(blob)
+		blob.Close()
+		if err != nil {
+			return err
+		}
+		layerNames[idx] = fileName(l.Digest) + "/layer.tar"
+		if err := writeEntry(tw, layerNames[idx], b); err != nil {
+			return err
+		}
+	}
+
+	manifest := []manifestItem{{
+		Config:   cfgName,
+		RepoTags: []string{ref.Name()},
+		Layers:   layerNames,
+	}}
+	mb, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return writeEntry(tw, "manifest.json", mb)
+}
+
+func writeEntry(tw *tar.Writer, name string, b []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Size: int64(len(b)),
+		Mode: 0644,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
+
+func fileName(h v1.Hash) string {
+	return strings.TrimPrefix(h.String(), "sha256:")
+}
+
+// manifestItem mirrors tarball.manifestItem; duplicated here (rather than
+// exported from the tarball package) since the two packages serialize it
+// for different purposes.
+type manifestItem struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package google provides credential resolution and registry helpers
+// specific to Google Container Registry and Artifact Registry.
+package google
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/google/go-containerregistry/authn"
+	"github.com/google/go-containerregistry/name"
+)
+
+// metadataTokenURL is the GCE/GKE metadata server endpoint for the default
+// service account's access token.
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// Keychain resolves credentials for *.gcr.io and *-docker.pkg.dev
+// registries, trying, in order: GOOGLE_APPLICATION_CREDENTIALS, the active
+// gcloud configuration, and the GCE/GKE metadata server. Registries it
+// doesn't recognize resolve to authn.Anonymous, so it composes cleanly with
+// other keychains via authn.NewMultiKeychain.
+var Keychain authn.Keychain = &googleKeychain{}
+
+type googleKeychain struct{}
+
+var _ authn.Keychain = (*googleKeychain)(nil)
+
+func (gk *googleKeychain) Resolve(reg name.Registry) (authn.Authenticator, error) {
+	if !isGoogle(reg) {
+		return authn.Anonymous, nil
+	}
+	for _, resolve := range []func() (authn.Authenticator, error){
+		fromEnv,
+		fromGcloud,
+		fromMetadata,
+	} {
+		auth, err := resolve()
+		if err != nil {
+			return nil, err
+		}
+		if auth != nil {
+			return auth, nil
+		}
+	}
+	return authn.Anonymous, nil
+}
+
+func isGoogle(reg name.Registry) bool {
+	host := reg.String()
+	return host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") || strings.HasSuffix(host, "-docker.pkg.dev")
+}
+
+// fromEnv resolves a service account key file named by
+// GOOGLE_APPLICATION_CREDENTIALS. GCR/AR accept the raw JSON key as a Basic
+// auth password with the fixed username "_json_key".
+func fromEnv() (authn.Authenticator, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &authn.Basic{Username: "_json_key", Password: string(b)}, nil
+}
+
+// fromGcloud shells out to the gcloud CLI, if present, for an access token
+// from its active configuration.
+func fromGcloud() (authn.Authenticator, error) {
+	path, err := exec.LookPath("gcloud")
+	if err != nil {
+		return nil, nil
+	}
+	out, err := exec.Command(path, "config", "config-helper", "--format=json").Output()
+	if err != nil {
+		return nil, nil
+	}
+	var parsed struct {
+		Credential struct {
+			AccessToken string `json:"access_token"`
+		} `json:"credential"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, err
+	}
+	if parsed.Credential.AccessToken == "" {
+		return nil, nil
+	}
+	return &authn.Basic{Username: "oauth2accesstoken", Password: parsed.Credential.AccessToken}, nil
+}
+
+// fromMetadata asks the GCE/GKE metadata server for the default service
+// account's access token. It returns (nil, nil), not an error, when the
+// metadata server isn't reachable, since that just means we're not running
+// on GCE/GKE.
+func fromMetadata() (authn.Authenticator, error) {
+	req, err := http.NewRequest(http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, err
+	}
+	return &authn.Basic{Username: "oauth2accesstoken", Password: tok.AccessToken}, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-containerregistry/authn"
+	"github.com/google/go-containerregistry/name"
+	"github.com/google/go-containerregistry/v1/remote/transport"
+)
+
+// Tags is GCR's response to a tags/list request. Unlike the standard
+// distribution spec, which only returns tag names, GCR also reports
+// per-digest metadata, which lets callers enumerate images without a
+// manifest GET per tag.
+type Tags struct {
+	Name      string                  `json:"name"`
+	Tags      []string                `json:"tags"`
+	Manifests map[string]ManifestInfo `json:"manifest"`
+}
+
+// ManifestInfo describes a single digest in a Tags response.
+type ManifestInfo struct {
+	Size      uint64   `json:"imageSizeBytes,string"`
+	MediaType string   `json:"mediaType"`
+	Tags      []string `json:"tag"`
+	Created   string   `json:"timeCreatedMs"`
+	Uploaded  string   `json:"timeUploadedMs"`
+}
+
+// List calls repo's tags/list endpoint and returns GCR's (non-standard)
+// response, which includes per-digest tag and timestamp metadata.
+func List(repo name.Repository, auth authn.Authenticator, t http.RoundTripper) (*Tags, error) {
+	tr, err := transport.New(repo, auth, t, transport.PullScope)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: tr}
+
+	u := url.URL{
+		Scheme: transport.Scheme(repo.Registry),
+		Host:   repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/tags/list", repo.RepositoryStr()),
+	}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing tags for %s: unexpected status %d", repo, resp.StatusCode)
+	}
+
+	tags := Tags{}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+	return &tags, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import "testing"
+
+func TestPlatformMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     Platform
+		other Platform
+		want  bool
+	}{{
+		name:  "exact match",
+		p:     Platform{OS: "linux", Architecture: "amd64"},
+		other: Platform{OS: "linux", Architecture: "amd64"},
+		want:  true,
+	}, {
+		name:  "different os",
+		p:     Platform{OS: "linux", Architecture: "amd64"},
+		other: Platform{OS: "windows", Architecture: "amd64"},
+		want:  false,
+	}, {
+		name:  "different arch",
+		p:     Platform{OS: "linux", Architecture: "amd64"},
+		other: Platform{OS: "linux", Architecture: "arm64"},
+		want:  false,
+	}, {
+		name:  "variant mismatch",
+		p:     Platform{OS: "linux", Architecture: "arm", Variant: "v8"},
+		other: Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		want:  false,
+	}, {
+		name:  "variant wildcard",
+		p:     Platform{OS: "linux", Architecture: "arm"},
+		other: Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		want:  true,
+	}, {
+		name:  "empty p is a wildcard",
+		p:     Platform{},
+		other: Platform{OS: "linux", Architecture: "amd64"},
+		want:  true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.p.Matches(test.other); got != test.want {
+				t.Errorf("Matches(%+v, %+v) = %v, want %v", test.p, test.other, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"strings"
+
+	"github.com/google/go-containerregistry/v1/types"
+)
+
+// manifestAccept is the set of media types we are willing to accept when
+// fetching a manifest. We list both the single-arch manifest types and the
+// multi-arch index types so that the registry may respond with either.
+var manifestAccept = []types.MediaType{
+	types.OCIManifestSchema1,
+	types.OCIImageIndex,
+	types.DockerManifestSchema2,
+	types.DockerManifestList,
+}
+
+func acceptHeader() string {
+	ss := make([]string, 0, len(manifestAccept))
+	for _, mt := range manifestAccept {
+		ss = append(ss, string(mt))
+	}
+	return strings.Join(ss, ", ")
+}
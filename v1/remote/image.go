@@ -15,12 +15,16 @@
 package remote
 
 import (
+	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sync"
 
 	"github.com/google/go-containerregistry/authn"
+	"github.com/google/go-containerregistry/internal/zstd"
 	"github.com/google/go-containerregistry/name"
 	"github.com/google/go-containerregistry/v1"
 	"github.com/google/go-containerregistry/v1/remote/transport"
@@ -31,25 +35,35 @@ import (
 type image struct {
 	ref    name.Reference
 	client *http.Client
+	opts   options
+
+	identifierOnce sync.Once
+	identifierErr  error
+	resolvedID     string
 }
 
 var _ v1.Image = (*image)(nil)
 
 // Image accesses a given image reference over the provided transport, with the provided authentication.
-func Image(ref name.Reference, auth authn.Authenticator, t http.RoundTripper) (v1.Image, error) {
+//
+// If the registry responds to the manifest request with a multi-arch image
+// index, the child manifest matching opts' platform (the host's platform by
+// default, see WithPlatform) is resolved transparently.
+func Image(ref name.Reference, auth authn.Authenticator, t http.RoundTripper, opts ...Option) (v1.Image, error) {
 	tr, err := transport.New(ref, auth, t, transport.PullScope)
 	if err != nil {
 		return nil, err
 	}
-	return image{
+	return &image{
 		ref: ref,
 		client: &http.Client{
 			Transport: tr,
 		},
+		opts: makeOptions(opts...),
 	}, nil
 }
 
-func (i image) url(resource, identifier string) url.URL {
+func (i *image) url(resource, identifier string) url.URL {
 	return url.URL{
 		Scheme: transport.Scheme(i.ref.Context().Registry),
 		Host:   i.ref.Context().RegistryStr(),
@@ -57,10 +71,59 @@ func (i image) url(resource, identifier string) url.URL {
 	}
 }
 
+// identifier returns the tag or digest this image should actually be read
+// from: i.ref's identifier, unless the registry resolves that identifier to
+// a multi-arch index, in which case we resolve it down to the digest of the
+// child manifest matching i.opts.platform. The result is resolved once and
+// cached, since every Manifest/ConfigFile/Blob call needs it.
+func (i *image) identifier() (string, error) {
+	i.identifierOnce.Do(func() {
+		i.resolvedID, i.identifierErr = i.resolveIdentifier()
+	})
+	return i.resolvedID, i.identifierErr
+}
+
+func (i *image) resolveIdentifier() (string, error) {
+	u := i.url("manifests", i.ref.Identifier())
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", acceptHeader())
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	mt := types.MediaType(resp.Header.Get("Content-Type"))
+	if !mt.IsIndex() {
+		return i.ref.Identifier(), nil
+	}
+
+	im, err := v1.ParseIndexManifest(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	for _, desc := range im.Manifests {
+		if desc.Platform == nil {
+			continue
+		}
+		if i.opts.platform.Matches(*desc.Platform) {
+			return desc.Digest.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no child manifest in %s matches platform %s", i.ref, i.opts.platform)
+}
+
 // TODO: refactor http request creation
 // TODO: cache config and manifest files
-func (i image) Manifest() (*v1.Manifest, error) {
-	u := i.url("manifests", i.ref.Identifier())
+func (i *image) Manifest() (*v1.Manifest, error) {
+	identifier, err := i.identifier()
+	if err != nil {
+		return nil, err
+	}
+	u := i.url("manifests", identifier)
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
 		return nil, err
@@ -74,7 +137,7 @@ func (i image) Manifest() (*v1.Manifest, error) {
 	return v1.ParseManifest(resp.Body)
 }
 
-func (i image) FSLayers() ([]v1.Hash, error) {
+func (i *image) FSLayers() ([]v1.Hash, error) {
 	manifest, err := i.Manifest()
 	if err != nil {
 		return nil, err
@@ -86,7 +149,7 @@ func (i image) FSLayers() ([]v1.Hash, error) {
 	return layers, nil
 }
 
-func (i image) DiffIDs() ([]v1.Hash, error) {
+func (i *image) DiffIDs() ([]v1.Hash, error) {
 	config, err := i.ConfigFile()
 	if err != nil {
 		return nil, err
@@ -94,7 +157,7 @@ func (i image) DiffIDs() ([]v1.Hash, error) {
 	return config.RootFS.DiffIDs, nil
 }
 
-func (i image) ConfigName() (v1.Hash, error) {
+func (i *image) ConfigName() (v1.Hash, error) {
 	manifest, err := i.Manifest()
 	if err != nil {
 		return v1.Hash{}, err
@@ -102,7 +165,7 @@ func (i image) ConfigName() (v1.Hash, error) {
 	return manifest.Config.Digest, nil
 }
 
-func (i image) BlobSet() (map[v1.Hash]struct{}, error) {
+func (i *image) BlobSet() (map[v1.Hash]struct{}, error) {
 	set := make(map[v1.Hash]struct{})
 	layers, err := i.FSLayers()
 	if err != nil {
@@ -119,9 +182,13 @@ func (i image) BlobSet() (map[v1.Hash]struct{}, error) {
 	return set, nil
 }
 
-func (i image) Digest() (v1.Hash, error) {
+func (i *image) Digest() (v1.Hash, error) {
 	// TODO: refactor this -- we can't just use i.Manifest() because of string formatting
-	u := i.url("manifests", i.ref.Identifier())
+	identifier, err := i.identifier()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	u := i.url("manifests", identifier)
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
 		return v1.Hash{}, err
@@ -135,12 +202,12 @@ func (i image) Digest() (v1.Hash, error) {
 	return v1.SHA256(resp.Body)
 }
 
-func (i image) MediaType() (types.MediaType, error) {
+func (i *image) MediaType() (types.MediaType, error) {
 	// TODO: how to coerce string into types.MediaType in go?
 	return types.OCIManifestSchema1, nil
 }
 
-func (i image) ConfigFile() (*v1.ConfigFile, error) {
+func (i *image) ConfigFile() (*v1.ConfigFile, error) {
 	hash, err := i.ConfigName()
 	if err != nil {
 		return nil, err
@@ -153,7 +220,7 @@ func (i image) ConfigFile() (*v1.ConfigFile, error) {
 	return v1.ParseConfigFile(body)
 }
 
-func (i image) BlobSize(h v1.Hash) (int64, error) {
+func (i *image) BlobSize(h v1.Hash) (int64, error) {
 	u := i.url("blobs", h.String())
 	resp, err := i.client.Head(u.String())
 	if err != nil {
@@ -162,7 +229,7 @@ func (i image) BlobSize(h v1.Hash) (int64, error) {
 	return resp.ContentLength, nil
 }
 
-func (i image) Blob(h v1.Hash) (io.ReadCloser, error) {
+func (i *image) Blob(h v1.Hash) (io.ReadCloser, error) {
 	u := i.url("blobs", h.String())
 	resp, err := i.client.Get(u.String())
 	if err != nil {
@@ -171,7 +238,7 @@ func (i image) Blob(h v1.Hash) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
-func (i image) Layer(h v1.Hash) (io.ReadCloser, error) {
+func (i *image) Layer(h v1.Hash) (io.ReadCloser, error) {
 	// TODO: pull this out into diffid_to_digest
 	layers, err := i.FSLayers()
 	if err != nil {
@@ -189,12 +256,99 @@ func (i image) Layer(h v1.Hash) (io.ReadCloser, error) {
 	return nil, fmt.Errorf("could not find Layer by diffid (%v)", h)
 }
 
-// TODO(mattmoor): xyzpdq
-func (i image) UncompressedBlob(h v1.Hash) (io.ReadCloser, error) {
-	return nil, fmt.Errorf("NYI: remote.UncompressedBlob(%v)", h)
+// errNoLayerDescriptor is descriptorFor's error when h simply isn't one of
+// the manifest's layers (e.g. it's the config blob), as opposed to the
+// manifest fetch itself failing.
+var errNoLayerDescriptor = errors.New("no layer descriptor for digest")
+
+// descriptorFor returns the manifest's Descriptor for the layer with the
+// given (compressed) digest, which carries the MediaType we need to pick a
+// decompression codec. It returns errNoLayerDescriptor if h names a valid
+// blob that just isn't a layer.
+func (i *image) descriptorFor(h v1.Hash) (v1.Descriptor, error) {
+	manifest, err := i.Manifest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	for _, l := range manifest.Layers {
+		if l.Digest == h {
+			return l, nil
+		}
+	}
+	return v1.Descriptor{}, fmt.Errorf("%w: %v", errNoLayerDescriptor, h)
+}
+
+// UncompressedBlob returns the layer or config blob identified by h,
+// decompressed according to its manifest MediaType (gzip or zstd); config
+// blobs, which have no layer descriptor, are returned as-is.
+func (i *image) UncompressedBlob(h v1.Hash) (io.ReadCloser, error) {
+	rc, err := i.Blob(h)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := i.descriptorFor(h)
+	if errors.Is(err, errNoLayerDescriptor) {
+		// Not a layer (e.g. the config blob): nothing to decompress.
+		return rc, nil
+	}
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return decompress(desc.MediaType, rc)
+}
+
+// UncompressedLayer returns the decompressed layer contents for the given
+// diff ID.
+func (i *image) UncompressedLayer(h v1.Hash) (io.ReadCloser, error) {
+	diffids, err := i.DiffIDs()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := i.FSLayers()
+	if err != nil {
+		return nil, err
+	}
+	for n, d := range diffids {
+		if d == h {
+			return i.UncompressedBlob(layers[n])
+		}
+	}
+	return nil, fmt.Errorf("could not find Layer by diffid (%v)", h)
+}
+
+// decompress wraps rc in a decompressing reader appropriate for mt. Layers
+// that are already uncompressed are passed through unchanged; zstd layers
+// are read with zstd, and every other media type is read as gzip.
+func decompress(mt types.MediaType, rc io.ReadCloser) (io.ReadCloser, error) {
+	switch {
+	case mt.IsZStdLayer():
+		zr, err := zstd.ReadCloser(rc)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		return zr, nil
+	case mt.IsUncompressedLayer():
+		return rc, nil
+	default:
+		gr, err := gzip.NewReader(rc)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		return &gzipReadCloser{Reader: gr, inner: rc}, nil
+	}
+}
+
+// gzipReadCloser closes both the gzip reader and the underlying stream it
+// wraps.
+type gzipReadCloser struct {
+	*gzip.Reader
+	inner io.ReadCloser
 }
 
-// TODO(mattmoor): xyzpdq
-func (i image) UncompressedLayer(h v1.Hash) (io.ReadCloser, error) {
-	return nil, fmt.Errorf("NYI: remote.UncompressedLayer(%v)", h)
+func (g *gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.inner.Close()
 }
@@ -0,0 +1,118 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-containerregistry/authn"
+	"github.com/google/go-containerregistry/name"
+	"github.com/google/go-containerregistry/v1"
+	"github.com/google/go-containerregistry/v1/remote/transport"
+	"github.com/google/go-containerregistry/v1/types"
+)
+
+// index accesses an image index from a remote registry.
+type index struct {
+	ref    name.Reference
+	client *http.Client
+	auth   authn.Authenticator
+	t      http.RoundTripper
+	opts   options
+}
+
+var _ v1.ImageIndex = (*index)(nil)
+
+// Index accesses a given image index reference over the provided transport, with the provided authentication.
+func Index(ref name.Reference, auth authn.Authenticator, t http.RoundTripper, opts ...Option) (v1.ImageIndex, error) {
+	tr, err := transport.New(ref, auth, t, transport.PullScope)
+	if err != nil {
+		return nil, err
+	}
+	return index{
+		ref: ref,
+		client: &http.Client{
+			Transport: tr,
+		},
+		auth: auth,
+		t:    t,
+		opts: makeOptions(opts...),
+	}, nil
+}
+
+func (i index) url(resource, identifier string) url.URL {
+	return url.URL{
+		Scheme: transport.Scheme(i.ref.Context().Registry),
+		Host:   i.ref.Context().RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/%s/%s", i.ref.Context().RepositoryStr(), resource, identifier),
+	}
+}
+
+func (i index) RawManifest() ([]byte, error) {
+	u := i.url("manifests", i.ref.Identifier())
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", acceptHeader())
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (i index) IndexManifest() (*v1.IndexManifest, error) {
+	b, err := i.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	return v1.ParseIndexManifest(bytes.NewReader(b))
+}
+
+func (i index) MediaType() (types.MediaType, error) {
+	return types.OCIImageIndex, nil
+}
+
+func (i index) Digest() (v1.Hash, error) {
+	b, err := i.RawManifest()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	return v1.SHA256(bytes.NewReader(b))
+}
+
+// Image returns the child v1.Image with the given digest.
+func (i index) Image(h v1.Hash) (v1.Image, error) {
+	d, err := name.NewDigest(fmt.Sprintf("%s@%s", i.ref.Context(), h), name.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+	return Image(d, i.auth, i.t, func(o *options) { *o = i.opts })
+}
+
+// ImageIndex returns the child v1.ImageIndex with the given digest.
+func (i index) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	d, err := name.NewDigest(fmt.Sprintf("%s@%s", i.ref.Context(), h), name.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+	return Index(d, i.auth, i.t, func(o *options) { *o = i.opts })
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"runtime"
+
+	"github.com/google/go-containerregistry/name"
+	"github.com/google/go-containerregistry/v1"
+)
+
+// options holds the result of applying functional options.
+type options struct {
+	platform   v1.Platform
+	progress   func(complete, total int64)
+	mountRepos []name.Repository
+}
+
+// Option is a functional option for remote operations.
+type Option func(*options)
+
+// WithPlatform sets the platform to resolve child manifests for when the
+// registry returns a multi-arch image index. If unset, it defaults to the
+// platform of the host this is running on.
+func WithPlatform(p v1.Platform) Option {
+	return func(o *options) {
+		o.platform = p
+	}
+}
+
+// WithProgress registers a callback that Write invokes after each chunk of
+// each blob is uploaded, with the cumulative bytes sent across all blobs and
+// the total bytes that will be sent. Both numbers cover only the blobs the
+// destination's HEAD check reports missing, so blobs it already has never
+// move either value. A missing blob that is then cross-mounted (see
+// WithMountRepos) counts toward the total and is added to the complete
+// count in one step when the mount succeeds.
+func WithProgress(f func(complete, total int64)) Option {
+	return func(o *options) {
+		o.progress = f
+	}
+}
+
+// WithMountRepos sets repositories that Write may cross-mount blobs from
+// instead of uploading them again, via the registry's
+// ?mount=<digest>&from=<repo> upload-initiation parameters. Only the first
+// of repos is offered as the mount source; the others are not tried. This
+// only saves work when the registry actually has the blob in that first
+// repository and the caller's token is scoped to pull from it; Write falls
+// back to a normal upload whenever a mount isn't honored.
+func WithMountRepos(repos ...name.Repository) Option {
+	return func(o *options) {
+		o.mountRepos = repos
+	}
+}
+
+func makeOptions(opts ...Option) options {
+	o := options{
+		platform: defaultPlatform(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func defaultPlatform() v1.Platform {
+	return v1.Platform{
+		OS:           runtime.GOOS,
+		Architecture: runtime.GOARCH,
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryOptions holds the result of applying RetryOptions.
+type retryOptions struct {
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// RetryOption customizes the transport returned by NewRetry.
+type RetryOption func(*retryOptions)
+
+// WithMaxAttempts bounds how many times a request is attempted in total,
+// including the first try.
+func WithMaxAttempts(n int) RetryOption {
+	return func(o *retryOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// WithInitialBackoff sets the backoff before the first retry; each
+// subsequent retry doubles it.
+func WithInitialBackoff(d time.Duration) RetryOption {
+	return func(o *retryOptions) {
+		o.backoff = d
+	}
+}
+
+type retryTransport struct {
+	inner http.RoundTripper
+	opts  retryOptions
+}
+
+// NewRetry wraps inner with exponential backoff and jitter, retrying
+// requests that fail with a connection error, a 5xx, a 429 (honoring
+// Retry-After), or any other 4xx unless its OCI error-response body carries
+// a code known to be non-retryable (see hasRetryableErrorCode). Responses
+// with such a code, like MANIFEST_INVALID, are returned immediately.
+func NewRetry(inner http.RoundTripper, opts ...RetryOption) http.RoundTripper {
+	o := retryOptions{
+		maxAttempts: 5,
+		backoff:     200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &retryTransport{inner: inner, opts: o}
+}
+
+func (rt *retryTransport) RoundTrip(in *http.Request) (*http.Response, error) {
+	// Buffer the body so we can replay it across attempts.
+	var body []byte
+	if in.Body != nil {
+		b, err := ioutil.ReadAll(in.Body)
+		in.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	backoff := rt.opts.backoff
+	var minWait time.Duration // a 429's Retry-After, which jitter must not undercut
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < rt.opts.maxAttempts; attempt++ {
+		if attempt > 0 {
+			wait := jitter(backoff)
+			if wait < minWait {
+				wait = minWait
+			}
+			time.Sleep(wait)
+			backoff *= 2
+			minWait = 0
+		}
+		if body != nil {
+			in.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err = rt.inner.RoundTrip(in)
+		if err != nil {
+			continue // connection reset, timeout, etc: always worth a retry
+		}
+		if !isRetryable(resp) {
+			return resp, nil
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if d, ok := retryAfter(resp); ok {
+				backoff, minWait = d, d
+			}
+		}
+		resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Every attempt came back retryable (e.g. a persistent 503); resp's body
+	// is already closed above, so returning it would leave the caller with
+	// a response they can't read. Report the exhaustion instead.
+	return nil, fmt.Errorf("giving up after %d attempts, last error: %s", rt.opts.maxAttempts, resp.Status)
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+func isRetryable(resp *http.Response) bool {
+	switch {
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode >= 400:
+		return hasRetryableErrorCode(resp)
+	default:
+		return false
+	}
+}
+
+// ociErrorResponse mirrors the distribution spec's error response body:
+// {"errors":[{"code":"...","message":"..."}]}.
+type ociErrorResponse struct {
+	Errors []struct {
+		Code string `json:"code"`
+	} `json:"errors"`
+}
+
+// nonRetryableCodes are OCI/distribution error codes that describe a
+// malformed or rejected request, which retrying won't fix.
+var nonRetryableCodes = map[string]bool{
+	"NAME_INVALID":     true,
+	"NAME_UNKNOWN":     true,
+	"TAG_INVALID":      true,
+	"DIGEST_INVALID":   true,
+	"SIZE_INVALID":     true,
+	"MANIFEST_INVALID": true,
+	"MANIFEST_UNKNOWN": true,
+	"BLOB_UNKNOWN":     true,
+	"UNAUTHORIZED":     true,
+	"DENIED":           true,
+	"UNSUPPORTED":      true,
+}
+
+// hasRetryableErrorCode inspects a 4xx body for an OCI error code. Bodies
+// we can't parse, or that carry no recognized code, are treated as
+// retryable (e.g. BLOB_UPLOAD_UNKNOWN, BLOB_UPLOAD_INVALID, or a gateway's
+// plain-text error page).
+func hasRetryableErrorCode(resp *http.Response) bool {
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return false
+	}
+	var oe ociErrorResponse
+	if err := json.Unmarshal(b, &oe); err != nil || len(oe.Errors) == 0 {
+		return true
+	}
+	for _, e := range oe.Errors {
+		if nonRetryableCodes[e.Code] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fixedStatusRoundTripper struct {
+	status int
+	calls  int
+}
+
+func (f *fixedStatusRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	f.calls++
+	return &http.Response{
+		Status:     http.StatusText(f.status),
+		StatusCode: f.status,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
+func TestRetryExhaustionReturnsError(t *testing.T) {
+	inner := &fixedStatusRoundTripper{status: http.StatusServiceUnavailable}
+	rt := NewRetry(inner, WithMaxAttempts(3), WithInitialBackoff(time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/v2/foo/manifests/latest", nil)
+	resp, err := rt.RoundTrip(req)
+	if err == nil {
+		t.Fatalf("RoundTrip() = %+v, nil; want a retry-exhaustion error", resp)
+	}
+	if resp != nil {
+		t.Errorf("RoundTrip() returned a non-nil response alongside an error: %+v", resp)
+	}
+	if inner.calls != 3 {
+		t.Errorf("inner RoundTrip called %d times, want 3 (WithMaxAttempts(3))", inner.calls)
+	}
+}
+
+func TestRetrySucceedsAfterTransientFailures(t *testing.T) {
+	inner := &flakyRoundTripper{failures: 2, okStatus: http.StatusOK}
+	rt := NewRetry(inner, WithMaxAttempts(5), WithInitialBackoff(time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/v2/foo/manifests/latest", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() returned error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("RoundTrip() status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+type flakyRoundTripper struct {
+	failures int
+	okStatus int
+	calls    int
+}
+
+func (f *flakyRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	f.calls++
+	status := f.okStatus
+	if f.calls <= f.failures {
+		status = http.StatusServiceUnavailable
+	}
+	return &http.Response{
+		Status:     http.StatusText(status),
+		StatusCode: status,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil
+}
@@ -0,0 +1,30 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+// Scope is the action(s) a caller intends to take against a repository,
+// e.g. "pull" or "push,pull". It is passed to New to scope the token
+// requested during the bearer auth handshake.
+type Scope string
+
+const (
+	// PullScope grants read-only access to a repository.
+	PullScope Scope = "pull"
+
+	// PushScope grants read/write access to a repository. Registries
+	// generally treat "push" as implying "pull", but we request both
+	// explicitly since not all of them do.
+	PushScope Scope = "push,pull"
+)
@@ -28,8 +28,17 @@ const (
 
 // New returns a new RoundTripper based on the provided RoundTripper that has been
 // setup to authenticate with the remote registry hosting "ref", in the capacity
-// laid out by the specified Scope.
+// laid out by the specified Scope. The returned RoundTripper retries transient
+// registry failures (5xx, 429, connection resets) with backoff; see NewRetry.
 func New(ref name.Reference, auth authn.Authenticator, t http.RoundTripper, a Scope) (http.RoundTripper, error) {
+	inner, err := newWithoutRetry(ref, auth, t, a)
+	if err != nil {
+		return nil, err
+	}
+	return NewRetry(inner), nil
+}
+
+func newWithoutRetry(ref name.Reference, auth authn.Authenticator, t http.RoundTripper, a Scope) (http.RoundTripper, error) {
 	// The handshake:
 	//  1. Use "t" to ping() the registry for the authentication challenge.
 	//
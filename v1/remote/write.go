@@ -0,0 +1,410 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/authn"
+	"github.com/google/go-containerregistry/name"
+	"github.com/google/go-containerregistry/v1"
+	"github.com/google/go-containerregistry/v1/remote/transport"
+)
+
+// uploadWorkers bounds how many layer uploads we run concurrently against
+// the registry.
+const uploadWorkers = 4
+
+// writer pushes a v1.Image to a remote registry.
+type writer struct {
+	ref    name.Reference
+	client *http.Client
+	img    v1.Image
+	opts   options
+
+	sent  int64 // bytes uploaded so far, across all blobs; updated atomically
+	total int64 // total bytes Write expects to send; set once before upload workers start
+}
+
+// Write pushes the provided img to the registry referenced by ref, using the
+// given authentication and transport.
+//
+// Existing blobs are skipped (after a HEAD check), the rest are uploaded
+// with a bounded pool of concurrent workers (small blobs in a single
+// monolithic PUT, larger ones with the chunked upload protocol), and the
+// manifest is PUT last so that it never references a blob the registry
+// doesn't have yet.
+func Write(ref name.Reference, img v1.Image, auth authn.Authenticator, t http.RoundTripper, opts ...Option) error {
+	tr, err := transport.New(ref, auth, t, transport.PushScope)
+	if err != nil {
+		return err
+	}
+	w := &writer{
+		ref:    ref,
+		client: &http.Client{Transport: tr},
+		img:    img,
+		opts:   makeOptions(opts...),
+	}
+	return w.write()
+}
+
+func (w *writer) url(path string) url.URL {
+	return url.URL{
+		Scheme: transport.Scheme(w.ref.Context().Registry),
+		Host:   w.ref.Context().RegistryStr(),
+		Path:   path,
+	}
+}
+
+func (w *writer) write() error {
+	blobs, total, err := w.blobsToUpload()
+	if err != nil {
+		return err
+	}
+	w.total = total
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, uploadWorkers)
+	for h, size := range blobs {
+		h, size := h, size
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := w.uploadOne(h, size); err != nil {
+				errOnce.Do(func() { firstErr = err })
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return w.commitManifest()
+}
+
+// blobsToUpload returns the digests (and sizes) of the layers and config
+// that the registry doesn't already have, along with the sum of their sizes
+// (the number of bytes Write actually expects to send).
+func (w *writer) blobsToUpload() (map[v1.Hash]int64, int64, error) {
+	set, err := w.img.BlobSet()
+	if err != nil {
+		return nil, 0, err
+	}
+	todo := map[v1.Hash]int64{}
+	var total int64
+	for h := range set {
+		if w.blobExists(h) {
+			continue
+		}
+		// Use the source image's own accounting, not a HEAD against the
+		// destination: the destination doesn't have this blob yet (that's
+		// why it's in todo), so a HEAD against it 404s and tells us nothing.
+		size, err := w.img.BlobSize(h)
+		if err != nil {
+			return nil, 0, err
+		}
+		total += size
+		todo[h] = size
+	}
+	return todo, total, nil
+}
+
+func (w *writer) blobExists(h v1.Hash) bool {
+	u := w.blobURL(h)
+	resp, err := w.client.Head(u.String())
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+func (w *writer) blobURL(h v1.Hash) url.URL {
+	return w.url(fmt.Sprintf("/v2/%s/blobs/%s", w.ref.Context().RepositoryStr(), h))
+}
+
+// uploadOne uploads a single blob (layer or config) by digest, skipping
+// straight to a monolithic PUT for small blobs and falling back to chunked
+// PATCH uploads otherwise. If w.opts.mountRepos names a repo the registry
+// can cross-mount h from, the upload is skipped entirely.
+func (w *writer) uploadOne(h v1.Hash, size int64) error {
+	location, mounted, minChunk, err := w.initiateUpload(h)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		w.trackProgress(size)
+		return nil
+	}
+
+	blob, err := w.img.Blob(h)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	const chunkThreshold = 1 << 20 // 1MiB: small blobs go up in one shot.
+	if size > 0 && size <= chunkThreshold {
+		return w.monolithicUpload(location, h, blob, size)
+	}
+	return w.chunkedUpload(location, h, blob, minChunk)
+}
+
+// initiateUpload starts a blob upload session, attempting a cross-repo mount
+// from w.opts.mountRepos first. It returns the upload URL the registry
+// handed back in the Location header (unset if mounted), whether the mount
+// was honored, and the minimum chunk size (if any) the registry advertised
+// via the OCI-Chunk-Min-Length response header.
+//
+// A zero-value h (as used by a streamed upload, whose digest isn't known
+// until the stream is fully read) never attempts a mount, since there's no
+// digest to mount by.
+func (w *writer) initiateUpload(h v1.Hash) (location string, mounted bool, minChunkSize int64, err error) {
+	u := w.url(fmt.Sprintf("/v2/%s/blobs/uploads/", w.ref.Context().RepositoryStr()))
+	if len(w.opts.mountRepos) > 0 && h != (v1.Hash{}) {
+		q := u.Query()
+		q.Set("mount", h.String())
+		q.Set("from", w.opts.mountRepos[0].RepositoryStr())
+		u.RawQuery = q.Encode()
+	}
+	resp, err := w.client.Post(u.String(), "", nil)
+	if err != nil {
+		return "", false, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		// The registry mounted the blob from the source repo; nothing left
+		// to upload.
+		return "", true, 0, nil
+	case http.StatusAccepted:
+		loc := resp.Header.Get("Location")
+		if loc == "" {
+			return "", false, 0, fmt.Errorf("initiating upload: no Location header in response")
+		}
+		return loc, false, parseChunkMinLength(resp), nil
+	default:
+		return "", false, 0, fmt.Errorf("initiating upload: unexpected status %d", resp.StatusCode)
+	}
+}
+
+// parseChunkMinLength reads the OCI-Chunk-Min-Length hint a registry may
+// return alongside an upload session, naming the smallest chunk size it
+// accepts for PATCH requests. It returns 0 if the header is absent or
+// unparseable, leaving the caller's own default in effect.
+func parseChunkMinLength(resp *http.Response) int64 {
+	v := resp.Header.Get("OCI-Chunk-Min-Length")
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// monolithicUpload PUTs the entire blob in one request, the fast path
+// registries support for small blobs.
+func (w *writer) monolithicUpload(location string, h v1.Hash, r io.Reader, size int64) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("digest", h.String())
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("uploading %s: unexpected status %d", h, resp.StatusCode)
+	}
+	w.trackProgress(size)
+	return nil
+}
+
+// defaultChunkSize is used when the registry's upload session doesn't
+// advertise an OCI-Chunk-Min-Length hint.
+const defaultChunkSize = 10 << 20 // 10MiB
+
+// chunkedUpload streams the blob to the registry as a series of PATCH
+// requests, then closes the upload with a final PUT. minChunkSize, as
+// reported by the registry via OCI-Chunk-Min-Length, is honored as a floor
+// on the chunk size we send.
+func (w *writer) chunkedUpload(location string, h v1.Hash, r io.Reader, minChunkSize int64) error {
+	chunkSize := int64(defaultChunkSize)
+	if minChunkSize > chunkSize {
+		chunkSize = minChunkSize
+	}
+	buf := make([]byte, chunkSize)
+	var offset int64
+
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			next, sent, err := w.patchChunk(location, buf[:n], offset)
+			if err != nil {
+				return err
+			}
+			location = next
+			if sent > offset {
+				offset = sent
+			} else {
+				offset += int64(n)
+			}
+			w.trackProgress(int64(n))
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	return w.finishUpload(location, h)
+}
+
+// patchChunk PATCHes a single chunk at offset and returns the upload's
+// (possibly relocated) URL along with the next offset the registry
+// confirmed receiving, per the Range header the distribution spec has it
+// return. If the registry omits Range, the caller's own offset tracking is
+// used instead.
+func (w *writer) patchChunk(location string, chunk []byte, offset int64) (nextLocation string, nextOffset int64, err error) {
+	req, err := http.NewRequest(http.MethodPatch, location, bytes.NewReader(chunk))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+int64(len(chunk))-1))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return "", 0, fmt.Errorf("uploading chunk at offset %d: unexpected status %d", offset, resp.StatusCode)
+	}
+	next := location
+	// The registry may relocate us to a new upload URL for the next chunk.
+	if loc := resp.Header.Get("Location"); loc != "" {
+		next = loc
+	}
+	confirmed, _ := parseRangeEnd(resp)
+	return next, confirmed, nil
+}
+
+// parseRangeEnd parses the inclusive "<start>-<end>" Range header a
+// registry returns after a chunk PATCH, reporting the offset the next
+// chunk should start at (end+1).
+func parseRangeEnd(resp *http.Response) (int64, bool) {
+	v := resp.Header.Get("Range")
+	if v == "" {
+		return 0, false
+	}
+	parts := strings.SplitN(v, "-", 2)
+	if len(parts) != 2 {
+		return 0, false
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return end + 1, true
+}
+
+func (w *writer) finishUpload(location string, h v1.Hash) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("digest", h.String())
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("finishing upload of %s: unexpected status %d", h, resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *writer) trackProgress(n int64) {
+	sent := atomic.AddInt64(&w.sent, n)
+	if w.opts.progress != nil {
+		w.opts.progress(sent, w.total)
+	}
+}
+
+// commitManifest PUTs the image's manifest, which must only happen once
+// every blob it references is confirmed present.
+func (w *writer) commitManifest() error {
+	raw, err := w.img.RawManifest()
+	if err != nil {
+		return err
+	}
+	mt, err := w.img.MediaType()
+	if err != nil {
+		return err
+	}
+	u := w.url(fmt.Sprintf("/v2/%s/manifests/%s", w.ref.Context().RepositoryStr(), w.ref.Identifier()))
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", string(mt))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("pushing manifest: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
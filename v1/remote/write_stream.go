@@ -0,0 +1,102 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/google/go-containerregistry/authn"
+	"github.com/google/go-containerregistry/name"
+	"github.com/google/go-containerregistry/v1"
+	"github.com/google/go-containerregistry/v1/remote/transport"
+	"github.com/google/go-containerregistry/v1/stream"
+)
+
+// WriteLayer uploads l to the repository ref refers to, using the chunked
+// upload protocol. Unlike uploadOne, it doesn't know l's digest up front:
+// it finalizes with PUT ?digest= only once l's underlying stream has been
+// fully read and closed, at which point l.Digest has been computed.
+//
+// This is the building block for pushing layers piped straight from
+// `docker save` or build output, without buffering them to disk first.
+func WriteLayer(ref name.Reference, l *stream.Layer, auth authn.Authenticator, t http.RoundTripper, opts ...Option) error {
+	tr, err := transport.New(ref, auth, t, transport.PushScope)
+	if err != nil {
+		return err
+	}
+	w := &writer{
+		ref:    ref,
+		client: &http.Client{Transport: tr},
+		opts:   makeOptions(opts...),
+	}
+	return w.uploadStreamedLayer(l)
+}
+
+func (w *writer) uploadStreamedLayer(l *stream.Layer) error {
+	rc, err := l.Compressed()
+	if err != nil {
+		return err
+	}
+
+	// l's digest isn't known until it's fully read, so there's nothing to
+	// mount by; initiateUpload is called with the zero Hash to skip that.
+	location, _, minChunk, err := w.initiateUpload(v1.Hash{})
+	if err != nil {
+		rc.Close()
+		return err
+	}
+
+	chunkSize := int64(defaultChunkSize)
+	if minChunk > chunkSize {
+		chunkSize = minChunk
+	}
+	buf := make([]byte, chunkSize)
+	var offset int64
+	for {
+		n, readErr := io.ReadFull(rc, buf)
+		if n > 0 {
+			next, sent, err := w.patchChunk(location, buf[:n], offset)
+			if err != nil {
+				rc.Close()
+				return err
+			}
+			location = next
+			if sent > offset {
+				offset = sent
+			} else {
+				offset += int64(n)
+			}
+			w.trackProgress(int64(n))
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			rc.Close()
+			return readErr
+		}
+	}
+
+	// Closing the stream is what computes l's digest.
+	if err := rc.Close(); err != nil {
+		return err
+	}
+	h, err := l.Digest()
+	if err != nil {
+		return err
+	}
+	return w.finishUpload(location, h)
+}
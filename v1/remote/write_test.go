@@ -0,0 +1,207 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/name"
+	"github.com/google/go-containerregistry/v1"
+	"github.com/google/go-containerregistry/v1/types"
+)
+
+// fakeImage is a v1.Image backed by in-memory blobs, keyed by digest.
+type fakeImage struct {
+	blobs map[v1.Hash][]byte
+}
+
+func (f *fakeImage) BlobSet() (map[v1.Hash]struct{}, error) {
+	set := make(map[v1.Hash]struct{}, len(f.blobs))
+	for h := range f.blobs {
+		set[h] = struct{}{}
+	}
+	return set, nil
+}
+
+func (f *fakeImage) BlobSize(h v1.Hash) (int64, error) {
+	b, ok := f.blobs[h]
+	if !ok {
+		return 0, fmt.Errorf("no such blob %s", h)
+	}
+	return int64(len(b)), nil
+}
+
+func (f *fakeImage) Blob(h v1.Hash) (io.ReadCloser, error) {
+	b, ok := f.blobs[h]
+	if !ok {
+		return nil, fmt.Errorf("no such blob %s", h)
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (f *fakeImage) RawManifest() ([]byte, error)        { return []byte(`{}`), nil }
+func (f *fakeImage) MediaType() (types.MediaType, error) { return types.DockerManifestSchema2, nil }
+func (f *fakeImage) Manifest() (*v1.Manifest, error)     { return nil, fmt.Errorf("not implemented") }
+func (f *fakeImage) Digest() (v1.Hash, error)            { return v1.Hash{}, fmt.Errorf("not implemented") }
+func (f *fakeImage) ConfigName() (v1.Hash, error)        { return v1.Hash{}, fmt.Errorf("not implemented") }
+func (f *fakeImage) ConfigFile() (*v1.ConfigFile, error) { return nil, fmt.Errorf("not implemented") }
+func (f *fakeImage) FSLayers() ([]v1.Hash, error)        { return nil, fmt.Errorf("not implemented") }
+func (f *fakeImage) DiffIDs() ([]v1.Hash, error)         { return nil, fmt.Errorf("not implemented") }
+func (f *fakeImage) Layer(h v1.Hash) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeImage) UncompressedBlob(h v1.Hash) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeImage) UncompressedLayer(h v1.Hash) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+var _ v1.Image = (*fakeImage)(nil)
+
+func hashOf(b []byte) v1.Hash {
+	h, err := v1.NewHash(fmt.Sprintf("sha256:%x", sha256.Sum256(b)))
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// registryServer fakes just enough of the distribution protocol for
+// writer.write: a HEAD that reports every blob missing, a POST that starts
+// an upload session, PATCH/PUT that accept any chunk or monolithic body,
+// and a PUT for the manifest.
+func registryServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/repo/blobs/uploads/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "http://"+r.Host+"/v2/repo/blobs/uploads/session")
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("/v2/repo/blobs/uploads/session", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+		}
+	})
+	mux.HandleFunc("/v2/repo/blobs/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("/v2/repo/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestWriteMonolithicAndChunkedSplit(t *testing.T) {
+	server := registryServer(t)
+	defer server.Close()
+
+	small := bytes.Repeat([]byte{1}, 100)
+	big := bytes.Repeat([]byte{2}, 2<<20) // 2MiB: above the monolithic threshold.
+	img := &fakeImage{blobs: map[v1.Hash][]byte{
+		hashOf(small): small,
+		hashOf(big):   big,
+	}}
+
+	ref, err := name.NewTag(server.Listener.Addr().String()+"/repo:latest", name.WeakValidation)
+	if err != nil {
+		t.Fatalf("NewTag() returned error: %v", err)
+	}
+
+	var mu sync.Mutex
+	var lastComplete, lastTotal int64
+	w := &writer{
+		ref:    ref,
+		client: server.Client(),
+		img:    img,
+		opts: options{
+			progress: func(complete, total int64) {
+				mu.Lock()
+				defer mu.Unlock()
+				lastComplete, lastTotal = complete, total
+			},
+		},
+	}
+	if err := w.write(); err != nil {
+		t.Fatalf("write() returned error: %v", err)
+	}
+
+	wantTotal := int64(len(small) + len(big))
+	mu.Lock()
+	defer mu.Unlock()
+	if lastTotal != wantTotal {
+		t.Errorf("final progress total = %d, want %d (sum of the source image's own blob sizes)", lastTotal, wantTotal)
+	}
+	if lastComplete != wantTotal {
+		t.Errorf("final progress complete = %d, want %d", lastComplete, wantTotal)
+	}
+}
+
+func TestBlobsToUploadSkipsExistingAndSumsSourceSizes(t *testing.T) {
+	mux := http.NewServeMux()
+	present := bytes.Repeat([]byte{3}, 10)
+	missing := bytes.Repeat([]byte{4}, 4<<20) // 4MiB: large enough that a HEAD-based
+	// estimate against the destination (which 404s, since it's missing) would read 0,
+	// masking the bug the destination-HEAD approach had.
+	img := &fakeImage{blobs: map[v1.Hash][]byte{
+		hashOf(present): present,
+		hashOf(missing): missing,
+	}}
+	mux.HandleFunc("/v2/repo/blobs/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+		if bytes.Contains([]byte(r.URL.Path), []byte(hashOf(present).String())) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ref, err := name.NewTag(server.Listener.Addr().String()+"/repo:latest", name.WeakValidation)
+	if err != nil {
+		t.Fatalf("NewTag() returned error: %v", err)
+	}
+	w := &writer{ref: ref, client: server.Client(), img: img, opts: makeOptions()}
+
+	todo, total, err := w.blobsToUpload()
+	if err != nil {
+		t.Fatalf("blobsToUpload() returned error: %v", err)
+	}
+	if _, ok := todo[hashOf(present)]; ok {
+		t.Errorf("blobsToUpload() included a blob the destination already has")
+	}
+	if _, ok := todo[hashOf(missing)]; !ok {
+		t.Errorf("blobsToUpload() omitted a blob the destination is missing")
+	}
+	if want := int64(len(missing)); total != want {
+		t.Errorf("blobsToUpload() total = %d, want %d (the source's size for the missing blob)", total, want)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stream implements a v1.Layer that computes its digest, diff ID,
+// and size as it is streamed, instead of requiring its contents be
+// buffered up front. This lets a push pipe `docker save` or build output
+// straight to a registry in constant memory.
+package stream
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/go-containerregistry/internal/zstd"
+	"github.com/google/go-containerregistry/v1"
+	"github.com/google/go-containerregistry/v1/types"
+)
+
+// ErrNotComputed is returned by Digest, DiffID, and Size before Compressed
+// has been read to EOF and closed.
+var ErrNotComputed = errors.New("stream: digest/diffID/size not computed until stream is consumed")
+
+// errConsumed is returned by a second call to Compressed; streamed layers
+// can only be read once.
+var errConsumed = errors.New("stream: Compressed called more than once")
+
+// Layer is a v1.Layer backed by a single underlying io.ReadCloser. Its
+// Digest, DiffID, and Size are unknown until that reader has been drained
+// by a caller (typically a registry push using the chunked upload
+// protocol) and closed, at which point they're computed and cached.
+type Layer struct {
+	blob      io.ReadCloser
+	mediaType types.MediaType
+
+	mu       sync.Mutex
+	consumed bool
+	computed bool
+	digest   v1.Hash
+	diffID   v1.Hash
+	size     int64
+}
+
+var _ v1.Layer = (*Layer)(nil)
+
+// NewLayer returns a Layer that streams rc as its compressed contents,
+// reporting mediaType from MediaType().
+func NewLayer(rc io.ReadCloser, mediaType types.MediaType) *Layer {
+	return &Layer{blob: rc, mediaType: mediaType}
+}
+
+// Compressed returns a reader over the layer's compressed contents. It may
+// only be called once: draining the returned reader to EOF and closing it
+// is what computes Digest, DiffID, and Size.
+func (l *Layer) Compressed() (io.ReadCloser, error) {
+	l.mu.Lock()
+	if l.consumed {
+		l.mu.Unlock()
+		return nil, errConsumed
+	}
+	l.consumed = true
+	l.mu.Unlock()
+
+	// We need both the digest of the compressed bytes flowing past the
+	// caller and the digest of what they decompress to (the diff ID).
+	// Decompress a second copy of the stream (with the codec l.mediaType
+	// names), fed via a pipe, concurrently with the caller's read of the
+	// first.
+	compressedHash := sha256.New()
+	pr, pw := io.Pipe()
+	uncompressedHash := sha256.New()
+	done := make(chan error, 1)
+	go func() {
+		dr, err := decompressingReader(l.mediaType, pr)
+		if err != nil {
+			pr.CloseWithError(err)
+			done <- err
+			return
+		}
+		_, err = io.Copy(uncompressedHash, dr)
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	counting := &countingReader{r: io.TeeReader(l.blob, io.MultiWriter(compressedHash, pw))}
+	return &onCloseReader{
+		r: counting,
+		onClose: func() error {
+			pw.Close()
+			if err := <-done; err != nil && err != io.EOF {
+				return err
+			}
+			if err := l.blob.Close(); err != nil {
+				return err
+			}
+
+			digest, err := v1.NewHash(fmt.Sprintf("sha256:%x", compressedHash.Sum(nil)))
+			if err != nil {
+				return err
+			}
+			diffID, err := v1.NewHash(fmt.Sprintf("sha256:%x", uncompressedHash.Sum(nil)))
+			if err != nil {
+				return err
+			}
+
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.digest, l.diffID, l.size, l.computed = digest, diffID, counting.n, true
+			return nil
+		},
+	}, nil
+}
+
+// decompressingReader returns a reader over r's uncompressed contents,
+// picking a codec from mt the same way remote.decompress does: zstd or
+// gzip, or r itself for layers that are already uncompressed.
+func decompressingReader(mt types.MediaType, r io.Reader) (io.Reader, error) {
+	switch {
+	case mt.IsZStdLayer():
+		return zstd.ReadCloser(r)
+	case mt.IsUncompressedLayer():
+		return r, nil
+	default:
+		return gzip.NewReader(r)
+	}
+}
+
+// Uncompressed is not supported: a streamed layer is consumed exactly once,
+// by Compressed, as part of a push.
+func (l *Layer) Uncompressed() (io.ReadCloser, error) {
+	return nil, errors.New("stream: Uncompressed is not supported, use Compressed")
+}
+
+// Digest returns the Hash of the compressed layer. It returns
+// ErrNotComputed until Compressed's reader has been drained and closed.
+func (l *Layer) Digest() (v1.Hash, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.computed {
+		return v1.Hash{}, ErrNotComputed
+	}
+	return l.digest, nil
+}
+
+// DiffID returns the Hash of the uncompressed layer. It returns
+// ErrNotComputed until Compressed's reader has been drained and closed.
+func (l *Layer) DiffID() (v1.Hash, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.computed {
+		return v1.Hash{}, ErrNotComputed
+	}
+	return l.diffID, nil
+}
+
+// Size returns the compressed size of the layer. It returns ErrNotComputed
+// until Compressed's reader has been drained and closed.
+func (l *Layer) Size() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.computed {
+		return 0, ErrNotComputed
+	}
+	return l.size, nil
+}
+
+// MediaType returns the media type passed to NewLayer.
+func (l *Layer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type onCloseReader struct {
+	r       io.Reader
+	onClose func() error
+}
+
+func (o *onCloseReader) Read(p []byte) (int, error) {
+	return o.r.Read(p)
+}
+
+func (o *onCloseReader) Close() error {
+	return o.onClose()
+}
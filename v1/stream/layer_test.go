@@ -0,0 +1,97 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-containerregistry/internal/zstd"
+	"github.com/google/go-containerregistry/v1/types"
+)
+
+// TestLayerCodecs checks that Compressed's digest/diffID computation
+// dispatches on MediaType the same way remote.decompress does, for both a
+// gzip layer and a zstd one (chunk0-6).
+func TestLayerCodecs(t *testing.T) {
+	tests := []struct {
+		name     string
+		mt       types.MediaType
+		compress func([]byte) []byte
+	}{
+		{"gzip", types.DockerLayer, gzipBytes},
+		{"zstd", types.OCILayerZStd, zstdBytes},
+	}
+	want := []byte("some uncompressed layer contents, repeated twice. some uncompressed layer contents, repeated twice.")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compressed := test.compress(want)
+			l := NewLayer(ioutil.NopCloser(bytes.NewReader(compressed)), test.mt)
+
+			rc, err := l.Compressed()
+			if err != nil {
+				t.Fatalf("Compressed() returned error: %v", err)
+			}
+			got, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("reading Compressed(): %v", err)
+			}
+			if err := rc.Close(); err != nil {
+				t.Fatalf("Close() returned error: %v", err)
+			}
+			if !bytes.Equal(got, compressed) {
+				t.Errorf("Compressed() bytes didn't round-trip")
+			}
+
+			wantDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(compressed))
+			if gotDigest, err := l.Digest(); err != nil {
+				t.Errorf("Digest() returned error: %v", err)
+			} else if gotDigest.String() != wantDigest {
+				t.Errorf("Digest() = %s, want %s", gotDigest, wantDigest)
+			}
+
+			wantDiffID := fmt.Sprintf("sha256:%x", sha256.Sum256(want))
+			if gotDiffID, err := l.DiffID(); err != nil {
+				t.Errorf("DiffID() returned error: %v", err)
+			} else if gotDiffID.String() != wantDiffID {
+				t.Errorf("DiffID() = %s, want %s", gotDiffID, wantDiffID)
+			}
+		})
+	}
+}
+
+func gzipBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(b)
+	gz.Close()
+	return buf.Bytes()
+}
+
+func zstdBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	w, err := zstd.WriteCloser(&buf, 3)
+	if err != nil {
+		panic(err)
+	}
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,297 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tarball implements v1.Image on top of `docker save`-style tarball
+// archives, such as the ones produced by `docker save` or the daemon's
+// image export API.
+package tarball
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/google/go-containerregistry/v1"
+	"github.com/google/go-containerregistry/v1/types"
+)
+
+// Opener returns a fresh reader over the tarball's bytes. It is called once
+// per scan, since a tar archive can only be read sequentially.
+type Opener func() (io.ReadCloser, error)
+
+type image struct {
+	opener Opener
+}
+
+var _ v1.Image = (*image)(nil)
+
+// Image exposes a tarball as a v1.Image.
+func Image(opener Opener) (v1.Image, error) {
+	return &image{opener: opener}, nil
+}
+
+// manifestItem mirrors a single entry of the tarball's top-level
+// manifest.json, as written by `docker save`.
+type manifestItem struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+func (i *image) manifestItem() (manifestItem, error) {
+	b, err := i.readFile("manifest.json")
+	if err != nil {
+		return manifestItem{}, err
+	}
+	var items []manifestItem
+	if err := json.Unmarshal(b, &items); err != nil {
+		return manifestItem{}, err
+	}
+	if len(items) != 1 {
+		return manifestItem{}, fmt.Errorf("tarball: expected one manifest.json entry, found %d", len(items))
+	}
+	return items[0], nil
+}
+
+// readFile scans the tarball for the named entry and returns its contents.
+func (i *image) readFile(name string) ([]byte, error) {
+	r, err := i.opener()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("tarball: no such file %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == name {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+func (i *image) ConfigFile() (*v1.ConfigFile, error) {
+	item, err := i.manifestItem()
+	if err != nil {
+		return nil, err
+	}
+	b, err := i.readFile(item.Config)
+	if err != nil {
+		return nil, err
+	}
+	return v1.ParseConfigFile(bytes.NewReader(b))
+}
+
+func (i *image) ConfigName() (v1.Hash, error) {
+	item, err := i.manifestItem()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	b, err := i.readFile(item.Config)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	return v1.SHA256(bytes.NewReader(b))
+}
+
+// FSLayers returns the digest of each layer tar, in the same order as the
+// config's DiffIDs.
+func (i *image) FSLayers() ([]v1.Hash, error) {
+	item, err := i.manifestItem()
+	if err != nil {
+		return nil, err
+	}
+	hs := make([]v1.Hash, 0, len(item.Layers))
+	for _, l := range item.Layers {
+		b, err := i.readFile(l)
+		if err != nil {
+			return nil, err
+		}
+		h, err := v1.SHA256(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		hs = append(hs, h)
+	}
+	return hs, nil
+}
+
+func (i *image) DiffIDs() ([]v1.Hash, error) {
+	cf, err := i.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return cf.RootFS.DiffIDs, nil
+}
+
+func (i *image) BlobSet() (map[v1.Hash]struct{}, error) {
+	set := map[v1.Hash]struct{}{}
+	layers, err := i.FSLayers()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range layers {
+		set[h] = struct{}{}
+	}
+	cn, err := i.ConfigName()
+	if err != nil {
+		return nil, err
+	}
+	set[cn] = struct{}{}
+	return set, nil
+}
+
+func (i *image) MediaType() (types.MediaType, error) {
+	return types.DockerManifestSchema2, nil
+}
+
+// RawManifest synthesizes a distribution-schema2 manifest, since the
+// tarball's own manifest.json doesn't carry digests or sizes.
+func (i *image) RawManifest() ([]byte, error) {
+	item, err := i.manifestItem()
+	if err != nil {
+		return nil, err
+	}
+	cfgBytes, err := i.readFile(item.Config)
+	if err != nil {
+		return nil, err
+	}
+	cfgHash, err := v1.SHA256(bytes.NewReader(cfgBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	layers := make([]v1.Descriptor, 0, len(item.Layers))
+	for _, l := range item.Layers {
+		b, err := i.readFile(l)
+		if err != nil {
+			return nil, err
+		}
+		h, err := v1.SHA256(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, v1.Descriptor{
+			MediaType: types.DockerUncompressedLayer,
+			Size:      int64(len(b)),
+			Digest:    h,
+		})
+	}
+
+	m := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.DockerManifestSchema2,
+		Config: v1.Descriptor{
+			MediaType: types.DockerConfigJSON,
+			Size:      int64(len(cfgBytes)),
+			Digest:    cfgHash,
+		},
+		Layers: layers,
+	}
+	return json.Marshal(m)
+}
+
+func (i *image) Manifest() (*v1.Manifest, error) {
+	b, err := i.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	return v1.ParseManifest(bytes.NewReader(b))
+}
+
+func (i *image) Digest() (v1.Hash, error) {
+	b, err := i.RawManifest()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	return v1.SHA256(bytes.NewReader(b))
+}
+
+func (i *image) BlobSize(h v1.Hash) (int64, error) {
+	rc, err := i.Blob(h)
+	if err != nil {
+		return -1, err
+	}
+	defer rc.Close()
+	n, err := io.Copy(ioutil.Discard, rc)
+	return n, err
+}
+
+// Blob returns the raw (compressed) bytes of the config or a layer tar,
+// addressed by its digest.
+func (i *image) Blob(h v1.Hash) (io.ReadCloser, error) {
+	item, err := i.manifestItem()
+	if err != nil {
+		return nil, err
+	}
+	if cn, err := i.ConfigName(); err == nil && cn == h {
+		b, err := i.readFile(item.Config)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	layers, err := i.FSLayers()
+	if err != nil {
+		return nil, err
+	}
+	for n, l := range layers {
+		if l == h {
+			b, err := i.readFile(item.Layers[n])
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+	}
+	return nil, fmt.Errorf("tarball: no such blob %v", h)
+}
+
+// Layer returns the uncompressed layer tar matching the given diff ID.
+func (i *image) Layer(h v1.Hash) (io.ReadCloser, error) {
+	diffIDs, err := i.DiffIDs()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := i.FSLayers()
+	if err != nil {
+		return nil, err
+	}
+	for n, d := range diffIDs {
+		if d == h {
+			return i.Blob(layers[n])
+		}
+	}
+	return nil, fmt.Errorf("tarball: no such layer %v", h)
+}
+
+// UncompressedBlob is equivalent to Blob: the layer tars inside a tarball
+// archive are already uncompressed.
+func (i *image) UncompressedBlob(h v1.Hash) (io.ReadCloser, error) {
+	return i.Blob(h)
+}
+
+// UncompressedLayer is equivalent to Layer: the layer tars inside a
+// tarball archive are already uncompressed.
+func (i *image) UncompressedLayer(h v1.Hash) (io.ReadCloser, error) {
+	return i.Layer(h)
+}
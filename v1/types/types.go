@@ -0,0 +1,66 @@
+// Copyright 2018 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package types holds common OCI media type constants.
+package types
+
+// MediaType is the type of an individual layer, manifest, or index.
+type MediaType string
+
+// The collection of media types the registry client understands.
+const (
+	OCIImageIndex        MediaType = "application/vnd.oci.image.index.v1+json"
+	OCIManifestSchema1   MediaType = "application/vnd.oci.image.manifest.v1+json"
+	OCIConfigJSON        MediaType = "application/vnd.oci.image.config.v1+json"
+	OCILayer             MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+	OCIUncompressedLayer MediaType = "application/vnd.oci.image.layer.v1.tar"
+	OCILayerZStd         MediaType = "application/vnd.oci.image.layer.v1.tar+zstd"
+
+	DockerManifestSchema1       MediaType = "application/vnd.docker.distribution.manifest.v1+json"
+	DockerManifestSchema1Signed MediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	DockerManifestSchema2       MediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	DockerManifestList          MediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	DockerLayer                 MediaType = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	DockerConfigJSON            MediaType = "application/vnd.docker.container.image.v1+json"
+	DockerUncompressedLayer     MediaType = "application/vnd.docker.image.rootfs.diff.tar"
+	DockerForeignLayer          MediaType = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+)
+
+// IsIndex reports whether the given media type represents a multi-arch index
+// (as opposed to a single-arch manifest).
+func (m MediaType) IsIndex() bool {
+	switch m {
+	case OCIImageIndex, DockerManifestList:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsUncompressedLayer reports whether the given media type represents a
+// layer with no compression applied.
+func (m MediaType) IsUncompressedLayer() bool {
+	switch m {
+	case OCIUncompressedLayer, DockerUncompressedLayer:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsZStdLayer reports whether the given media type represents a
+// zstd-compressed layer.
+func (m MediaType) IsZStdLayer() bool {
+	return m == OCILayerZStd
+}